@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyIdentifyingHeaders are the headers a transparent or anonymous
+// proxy typically adds, which an elite proxy strips entirely.
+var proxyIdentifyingHeaders = []string{"via", "x-forwarded-for", "forwarded"}
+
+// headersEchoResponse models the {"headers": {...}} body returned by an
+// echo endpoint such as https://httpbin.org/headers.
+type headersEchoResponse struct {
+	Headers map[string]string `json:"headers"`
+}
+
+var (
+	directIPOnce sync.Once
+	directIP     string
+	directIPErr  error
+)
+
+// directIPAddress performs a one-shot direct (non-proxied) call to
+// cfg.IPCheckerURL and caches the result for the run, so classifying
+// every proxy doesn't repeat the lookup.
+func directIPAddress(cfg Config) (string, error) {
+	directIPOnce.Do(func() {
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Get(cfg.IPCheckerURL)
+		if err != nil {
+			directIPErr = err
+			return
+		}
+		defer resp.Body.Close()
+
+		var payload struct {
+			Origin string `json:"origin"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+			directIPErr = err
+			return
+		}
+		directIP = strings.TrimSpace(strings.Split(payload.Origin, ",")[0])
+	})
+	return directIP, directIPErr
+}
+
+// classifyAnonymity fetches cfg.HeadersEchoURL through client and compares
+// what the destination actually saw against our cached direct IP, rather
+// than trusting headers we set on our own outgoing request:
+//   - Transparent: the real IP is visible to the destination.
+//   - Anonymous: proxy-identifying headers are present but the real IP isn't.
+//   - Elite: neither the real IP nor proxy-identifying headers appear.
+func classifyAnonymity(cfg Config, client *http.Client) (string, error) {
+	realIP, err := directIPAddress(cfg)
+	if err != nil {
+		return "", fmt.Errorf("direct IP lookup failed: %v", err)
+	}
+
+	req, err := http.NewRequest("GET", cfg.HeadersEchoURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", getRandomUserAgent())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var echoed headersEchoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&echoed); err != nil {
+		return "", err
+	}
+
+	leaksRealIP := false
+	proxyHeaderSeen := false
+	for key, value := range echoed.Headers {
+		if strings.Contains(value, realIP) {
+			leaksRealIP = true
+		}
+		for _, identifying := range proxyIdentifyingHeaders {
+			if strings.EqualFold(key, identifying) {
+				proxyHeaderSeen = true
+			}
+		}
+	}
+
+	switch {
+	case leaksRealIP:
+		return "Transparent", nil
+	case proxyHeaderSeen:
+		return "Anonymous", nil
+	default:
+		return "Elite", nil
+	}
+}
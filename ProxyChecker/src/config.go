@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds all operator-tunable settings for the harvester. It is
+// normally loaded from a YAML file on disk and can be overridden with CLI
+// flags at startup.
+type Config struct {
+	HTTPPort                int      `yaml:"http_port"`
+	ProxyCheckers           int      `yaml:"proxy_checkers"`
+	IPCheckerURL            string   `yaml:"ip_checker_url"`
+	HeadersEchoURL          string   `yaml:"headers_echo_url"`
+	ProxyConnectTimeout     int      `yaml:"proxy_connect_timeout"` // seconds
+	ProxyPoolOurs           []string `yaml:"proxy_pool_ours"`       // "ip:port" entries we operate ourselves
+	ProxyPoolThirdparty     int      `yaml:"proxy_pool_thirdparty"` // 0 = unbounded
+	ThirdpartyTestURLs      []string `yaml:"thirdparty_test_urls"`
+	ThirdpartyBypassDomains []string `yaml:"thirdparty_bypass_domains"`
+	UserAgentsFile          string   `yaml:"useragents_file"`
+	SourcesFile             string   `yaml:"sources_file"`
+	StorePath               string   `yaml:"store_path"`
+	MinUptime               float64  `yaml:"min_uptime"`
+	MaxLatencyMs            int      `yaml:"max_latency_ms"`
+	RecheckIntervalSeconds  int      `yaml:"recheck_interval_seconds"`
+}
+
+
+// defaultConfig returns the baseline settings used when no config file is
+// present, or as a base that a config file's values are decoded on top of.
+func defaultConfig() Config {
+	return Config{
+		HTTPPort:            9000,
+		ProxyCheckers:       50,
+		IPCheckerURL:        "https://httpbin.org/ip",
+		HeadersEchoURL:      "https://httpbin.org/headers",
+		ProxyConnectTimeout: 5,
+		ProxyPoolThirdparty: 0,
+		ThirdpartyTestURLs:  []string{"https://httpbin.org/ip"},
+		UserAgentsFile:      "useragents.txt",
+		SourcesFile:         "ProxyList.json",
+		StorePath:           "proxies.db",
+		MinUptime:           0,
+		MaxLatencyMs:        0,
+	}
+}
+
+// loadConfig reads a YAML config file from path, decoding it on top of
+// defaultConfig so any field the file omits keeps its default. A missing
+// file is not treated as an error; it just means defaults are used.
+func loadConfig(path string) (Config, error) {
+	cfg := defaultConfig()
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return cfg, fmt.Errorf("failed to open config file: %v", err)
+	}
+	defer file.Close()
+
+	if err := yaml.NewDecoder(file).Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse config file: %v", err)
+	}
+	return cfg, nil
+}
+
+// serveMode is set by the -serve flag to enable the round-robin
+// load-balancing proxy server after a harvesting run completes.
+var serveMode bool
+
+// parseFlags binds CLI flags on top of cfg, letting operators override any
+// config file setting without editing it.
+func parseFlags(cfg *Config) {
+	flag.BoolVar(&serveMode, "serve", false, "expose validated proxies as a load-balancing proxy server on http_port")
+	flag.IntVar(&cfg.HTTPPort, "port", cfg.HTTPPort, "port for the load-balancing proxy and /stats endpoint")
+	flag.IntVar(&cfg.ProxyCheckers, "proxy-checkers", cfg.ProxyCheckers, "number of concurrent validation workers")
+	flag.StringVar(&cfg.IPCheckerURL, "ip-checker-url", cfg.IPCheckerURL, "endpoint used to validate proxies")
+	flag.StringVar(&cfg.HeadersEchoURL, "headers-echo-url", cfg.HeadersEchoURL, "endpoint that echoes back the headers it received, used for anonymity classification")
+	flag.IntVar(&cfg.ProxyConnectTimeout, "proxy-connect-timeout", cfg.ProxyConnectTimeout, "proxy connect timeout in seconds")
+	flag.StringVar(&cfg.UserAgentsFile, "useragents-file", cfg.UserAgentsFile, "path to the user agents list")
+	flag.StringVar(&cfg.SourcesFile, "sources-file", cfg.SourcesFile, "path to the proxy sources JSON")
+	flag.StringVar(&cfg.StorePath, "store-path", cfg.StorePath, "path to the SQLite store of harvested proxies")
+	flag.Float64Var(&cfg.MinUptime, "min-uptime", cfg.MinUptime, "minimum rolling uptime fraction (0-1) a proxy must have to be output")
+	flag.IntVar(&cfg.MaxLatencyMs, "max-latency", cfg.MaxLatencyMs, "maximum EMA response time in milliseconds a proxy may have to be output (0 = unbounded)")
+	flag.IntVar(&cfg.RecheckIntervalSeconds, "recheck-interval", cfg.RecheckIntervalSeconds, "seconds between periodic re-checks of known proxies (0 = disabled)")
+	flag.Parse()
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/panjf2000/ants/v2"
+)
+
+const (
+	queueHighWatermark = 500              // grow the pool once queued work exceeds this
+	idleShrinkAfter    = 30 * time.Second // shrink the pool after this much inactivity
+	scalerWindow       = 200              // number of recent results the scaler bases decisions on
+	minPoolSize        = 10
+	maxPoolSize        = 2000
+)
+
+// ValidationScaler runs proxy validation through a bounded, adaptively
+// sized worker pool instead of a goroutine per proxy. It tracks a rolling
+// window of recent results so it can grow the pool under load and back
+// off growth when success rate collapses, which usually means a source is
+// rate-limiting or dead rather than that more workers would help.
+type ValidationScaler struct {
+	pool *ants.PoolWithFunc
+
+	mu           sync.Mutex
+	queueDepth   int
+	lastGrowAt   time.Time
+	lastActivity time.Time
+	results      []scalerResult
+}
+
+type scalerResult struct {
+	success    bool
+	responseMs int
+}
+
+// newValidationScaler builds a scaler whose worker pool starts at
+// cfg.ProxyCheckers workers and calls handle for each submitted proxy.
+func newValidationScaler(cfg Config, handle func(Proxy)) (*ValidationScaler, error) {
+	size := cfg.ProxyCheckers
+	if size <= 0 {
+		size = minPoolSize
+	}
+
+	vs := &ValidationScaler{lastActivity: time.Now()}
+	pool, err := ants.NewPoolWithFunc(size, func(arg interface{}) {
+		handle(arg.(Proxy))
+	})
+	if err != nil {
+		return nil, err
+	}
+	vs.pool = pool
+	return vs, nil
+}
+
+// Submit queues proxy for validation, growing the pool first if the queue
+// is backing up.
+func (vs *ValidationScaler) Submit(proxy Proxy) error {
+	vs.mu.Lock()
+	vs.queueDepth++
+	depth := vs.queueDepth
+	vs.mu.Unlock()
+
+	if depth > queueHighWatermark {
+		vs.maybeGrow()
+	}
+	return vs.pool.Invoke(proxy)
+}
+
+// Done records a completed validation result for the scaler's rolling
+// window. The handler passed to newValidationScaler must call this once
+// per proxy it processes.
+func (vs *ValidationScaler) Done(success bool, responseMs int) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	vs.queueDepth--
+	vs.lastActivity = time.Now()
+	vs.results = append(vs.results, scalerResult{success, responseMs})
+	if len(vs.results) > scalerWindow {
+		vs.results = vs.results[len(vs.results)-scalerWindow:]
+	}
+}
+
+// maybeGrow grows the pool by 25% when the queue is backing up, unless
+// recent success rate has collapsed.
+func (vs *ValidationScaler) maybeGrow() {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	if time.Since(vs.lastGrowAt) < time.Second {
+		return
+	}
+	if len(vs.results) >= 20 && vs.successRateLocked() < 0.2 {
+		return
+	}
+
+	current := vs.pool.Cap()
+	next := current + current/4
+	if next > maxPoolSize {
+		next = maxPoolSize
+	}
+	if next > current {
+		vs.pool.Tune(next)
+		vs.lastGrowAt = time.Now()
+	}
+}
+
+// Shrink reduces the pool back towards minPoolSize once it has been idle
+// beyond idleShrinkAfter. Intended to be called from a periodic ticker.
+func (vs *ValidationScaler) Shrink() {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	if time.Since(vs.lastActivity) < idleShrinkAfter {
+		return
+	}
+
+	current := vs.pool.Cap()
+	next := current - current/4
+	if next < minPoolSize {
+		next = minPoolSize
+	}
+	if next < current {
+		vs.pool.Tune(next)
+	}
+}
+
+func (vs *ValidationScaler) successRateLocked() float64 {
+	if len(vs.results) == 0 {
+		return 1
+	}
+	successes := 0
+	for _, r := range vs.results {
+		if r.success {
+			successes++
+		}
+	}
+	return float64(successes) / float64(len(vs.results))
+}
+
+// Stats is the JSON payload served at /stats.
+type Stats struct {
+	PoolSize     int     `json:"pool_size"`
+	QueueDepth   int     `json:"queue_depth"`
+	SuccessRate  float64 `json:"success_rate"`
+	MedianRespMs int     `json:"median_response_ms"`
+}
+
+// Stats reports the current pool size, queue depth, rolling success rate,
+// and median response time of recent successful checks.
+func (vs *ValidationScaler) Stats() Stats {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	times := make([]int, 0, len(vs.results))
+	for _, r := range vs.results {
+		if r.success {
+			times = append(times, r.responseMs)
+		}
+	}
+	sort.Ints(times)
+
+	median := 0
+	if len(times) > 0 {
+		median = times[len(times)/2]
+	}
+
+	return Stats{
+		PoolSize:     vs.pool.Cap(),
+		QueueDepth:   vs.queueDepth,
+		SuccessRate:  vs.successRateLocked(),
+		MedianRespMs: median,
+	}
+}
+
+// ServeStats starts a background HTTP server exposing Stats as JSON at
+// /stats on addr.
+func (vs *ValidationScaler) ServeStats(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(vs.Stats())
+	})
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("stats server stopped: %v", err)
+		}
+	}()
+}
+
+// Release stops the pool from accepting new work. In-flight validations
+// still run to completion.
+func (vs *ValidationScaler) Release() {
+	vs.pool.Release()
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// protocolProbes lists the protocols checkAndClassifyProxy tries, in
+// order, against a candidate proxy. The first one that round-trips
+// successfully to the configured IP-checker URL wins and is recorded as
+// the proxy's Protocol.
+var protocolProbes = []string{"SOCKS5", "SOCKS4a", "SOCKS4", "HTTPS-CONNECT", "HTTP"}
+
+// probeProtocol attempts a single round-trip to cfg.IPCheckerURL through
+// client, returning the elapsed time on success.
+func probeProtocol(cfg Config, client *http.Client) (time.Duration, error) {
+	start := time.Now()
+	if err := probeURL(client, cfg.IPCheckerURL); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// probeURL performs a single GET against targetURL through client and
+// errors unless it returns 200.
+func probeURL(client *http.Client, targetURL string) error {
+	req, err := http.NewRequest("GET", targetURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", getRandomUserAgent())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// passesThirdpartyTests confirms a proxy that already round-tripped to the
+// IP-checker URL also works against every one of cfg.ThirdpartyTestURLs, so
+// a proxy isn't trusted on the strength of a single endpoint that happens
+// to be reachable. No test URLs configured means no extra check is made.
+func passesThirdpartyTests(cfg Config, client *http.Client) bool {
+	for _, testURL := range cfg.ThirdpartyTestURLs {
+		if err := probeURL(client, testURL); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// httpClientForProtocol builds an http.Client that routes through p using
+// the given protocol.
+func httpClientForProtocol(cfg Config, p Proxy, protocol string) (*http.Client, error) {
+	timeout := time.Duration(cfg.ProxyConnectTimeout) * time.Second
+	addr := net.JoinHostPort(p.IP, p.Port)
+
+	switch protocol {
+	case "SOCKS5":
+		dialer, err := proxy.SOCKS5("tcp", addr, nil, proxy.Direct)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Client{Transport: &http.Transport{Dial: dialer.Dial}, Timeout: timeout}, nil
+
+	case "SOCKS4", "SOCKS4a":
+		dialer := newSOCKS4Dialer(addr, protocol == "SOCKS4a")
+		return &http.Client{Transport: &http.Transport{Dial: dialer.Dial}, Timeout: timeout}, nil
+
+	case "HTTPS-CONNECT", "HTTP":
+		return &http.Client{
+			Transport: &http.Transport{Proxy: http.ProxyURL(&url.URL{Scheme: "http", Host: addr})},
+			Timeout:   timeout,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown protocol %q", protocol)
+	}
+}
+
+// socks4Dialer implements a minimal SOCKS4/SOCKS4a CONNECT handshake.
+// golang.org/x/net/proxy only ships a SOCKS5 dialer, so SOCKS4 support is
+// hand-rolled here.
+type socks4Dialer struct {
+	proxyAddr   string
+	useHostname bool // SOCKS4a: resolve the destination host at the proxy, not locally
+}
+
+func newSOCKS4Dialer(proxyAddr string, useHostname bool) *socks4Dialer {
+	return &socks4Dialer{proxyAddr: proxyAddr, useHostname: useHostname}
+}
+
+func (d *socks4Dialer) Dial(network, addr string) (net.Conn, error) {
+	conn, err := net.Dial("tcp", d.proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	req := []byte{0x04, 0x01, byte(port >> 8), byte(port)}
+	if d.useHostname {
+		req = append(req, 0, 0, 0, 1) // non-routable IP signals SOCKS4a to the server
+		req = append(req, 0)          // empty user ID
+		req = append(req, []byte(host)...)
+		req = append(req, 0)
+	} else {
+		ip := net.ParseIP(host)
+		if ip == nil || ip.To4() == nil {
+			conn.Close()
+			return nil, fmt.Errorf("socks4 requires an IPv4 address, got %q", host)
+		}
+		req = append(req, ip.To4()...)
+		req = append(req, 0) // empty user ID
+	}
+
+	if _, err := conn.Write(req); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	resp := make([]byte, 8)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp[1] != 0x5a {
+		conn.Close()
+		return nil, fmt.Errorf("socks4 connect rejected, code 0x%02x", resp[1])
+	}
+	return conn, nil
+}
+
+// ProxyResults buckets validated proxies by the protocol that was found to
+// work, so downstream consumers (the load balancer, output writers) can
+// request a proxy of a specific type instead of filtering a flat list.
+// Unlike a channel, it has no fixed capacity: a run that turns up far more
+// alive proxies of one protocol than another never blocks on dispatch.
+type ProxyResults struct {
+	SOCKS5  []Proxy
+	SOCKS4  []Proxy
+	SOCKS4a []Proxy
+	HTTP    []Proxy
+}
+
+func newProxyResults() *ProxyResults {
+	return &ProxyResults{}
+}
+
+// dispatch appends proxy to the slice matching its classified protocol.
+func (pr *ProxyResults) dispatch(proxy Proxy) {
+	switch proxy.Protocol {
+	case "SOCKS5":
+		pr.SOCKS5 = append(pr.SOCKS5, proxy)
+	case "SOCKS4":
+		pr.SOCKS4 = append(pr.SOCKS4, proxy)
+	case "SOCKS4a":
+		pr.SOCKS4a = append(pr.SOCKS4a, proxy)
+	default:
+		pr.HTTP = append(pr.HTTP, proxy)
+	}
+}
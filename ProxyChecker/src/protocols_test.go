@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io"
+	"net"
+	"testing"
+)
+
+// socks4Server is a minimal SOCKS4/4a handshake server for testing: it
+// records the raw request bytes it receives and replies with a fixed
+// response code.
+type socks4Server struct {
+	ln       net.Listener
+	respCode byte
+	gotReq   chan []byte
+}
+
+func startSOCKS4Server(t *testing.T, respCode byte) *socks4Server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start test listener: %v", err)
+	}
+
+	s := &socks4Server{ln: ln, respCode: respCode, gotReq: make(chan []byte, 1)}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// Read the fixed 8-byte header first, then the variable-length
+		// user ID / hostname fields up to their trailing NUL(s).
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		req := append([]byte{}, header...)
+		buf := make([]byte, 1)
+		nulsSeen := 0
+		wantNuls := 1
+		if header[4] == 0 && header[5] == 0 && header[6] == 0 && header[7] != 0 {
+			wantNuls = 2 // SOCKS4a: user ID NUL, then hostname NUL
+		}
+		for nulsSeen < wantNuls {
+			if _, err := io.ReadFull(conn, buf); err != nil {
+				return
+			}
+			req = append(req, buf[0])
+			if buf[0] == 0 {
+				nulsSeen++
+			}
+		}
+		s.gotReq <- req
+
+		conn.Write([]byte{0x00, s.respCode, 0, 0, 0, 0, 0, 0})
+	}()
+	return s
+}
+
+func (s *socks4Server) Close() { s.ln.Close() }
+
+func TestSOCKS4DialerHandshakeIPv4(t *testing.T) {
+	srv := startSOCKS4Server(t, 0x5a)
+	defer srv.Close()
+
+	dialer := newSOCKS4Dialer(srv.ln.Addr().String(), false)
+	conn, err := dialer.Dial("tcp", "93.184.216.34:443")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	req := <-srv.gotReq
+	want := []byte{0x04, 0x01, 0x01, 0xbb, 93, 184, 216, 34, 0}
+	if string(req) != string(want) {
+		t.Fatalf("request bytes = % x, want % x", req, want)
+	}
+}
+
+func TestSOCKS4aDialerHandshakeUsesHostname(t *testing.T) {
+	srv := startSOCKS4Server(t, 0x5a)
+	defer srv.Close()
+
+	dialer := newSOCKS4Dialer(srv.ln.Addr().String(), true)
+	conn, err := dialer.Dial("tcp", "example.com:443")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	req := <-srv.gotReq
+	want := append([]byte{0x04, 0x01, 0x01, 0xbb, 0, 0, 0, 1, 0}, append([]byte("example.com"), 0)...)
+	if string(req) != string(want) {
+		t.Fatalf("request bytes = % x, want % x", req, want)
+	}
+}
+
+func TestSOCKS4DialerRejectsNonIPv4Host(t *testing.T) {
+	dialer := newSOCKS4Dialer("127.0.0.1:1", false)
+	if _, err := dialer.Dial("tcp", "example.com:443"); err == nil {
+		t.Fatal("expected an error dialing a hostname through plain SOCKS4")
+	}
+}
+
+func TestSOCKS4DialerHandshakeRejected(t *testing.T) {
+	srv := startSOCKS4Server(t, 0x5b) // request rejected or failed
+	defer srv.Close()
+
+	dialer := newSOCKS4Dialer(srv.ln.Addr().String(), false)
+	if _, err := dialer.Dial("tcp", "93.184.216.34:443"); err == nil {
+		t.Fatal("expected an error when the proxy rejects the connect request")
+	}
+}
@@ -6,11 +6,11 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
-	"net/http"
-	"net/url"
 	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"golang.org/x/net/html"
@@ -29,7 +29,7 @@ type Proxy struct {
 }
 
 var userAgents []string
-var proxySources map[string]string
+var proxySources map[string]SourceConfig
 
 // Load user agents from a text file into memory
 func loadUserAgents(filePath string) error {
@@ -74,81 +74,48 @@ func getRandomUserAgent() string {
 	return userAgents[rand.Intn(len(userAgents))]
 }
 
-// Validate and classify proxy type and anonymity level
-func checkAndClassifyProxy(proxy Proxy) Proxy {
-	testURL := "https://httpbin.org/ip" // Test endpoint to verify proxy functionality
-	client := &http.Client{
-		Transport: &http.Transport{
-			Proxy: http.ProxyURL(&url.URL{
-				Scheme: "http",
-				Host:   fmt.Sprintf("%s:%s", proxy.IP, proxy.Port),
-			}),
-		},
-		Timeout: 5 * time.Second, // Fast timeout for quicker dead proxy detection
-	}
-
-	start := time.Now()
-	req, _ := http.NewRequest("GET", testURL, nil)
-	req.Header.Set("User-Agent", getRandomUserAgent())
-
-	resp, err := client.Do(req)
-	elapsed := time.Since(start).Milliseconds()
-
-	if err != nil || resp.StatusCode != 200 {
-		proxy.Alive = false
-		proxy.ResponseMs = int(elapsed)
-		return proxy
-	}
+// Validate and classify proxy type and anonymity level. Each protocol in
+// protocolProbes is tried in turn; the first one that round-trips to the
+// IP-checker URL wins and is recorded as the proxy's Protocol.
+func checkAndClassifyProxy(cfg Config, proxy Proxy) Proxy {
+	for _, protocol := range protocolProbes {
+		client, err := httpClientForProtocol(cfg, proxy, protocol)
+		if err != nil {
+			continue
+		}
+
+		elapsed, err := probeProtocol(cfg, client)
+		if err != nil {
+			continue
+		}
+		if !passesThirdpartyTests(cfg, client) {
+			continue
+		}
 
-	defer resp.Body.Close()
-	proxy.Alive = true
-	proxy.ResponseMs = int(elapsed)
-	proxy.Protocol = "HTTP/HTTPS"
+		proxy.Alive = true
+		proxy.Protocol = protocol
+		proxy.ResponseMs = int(elapsed.Milliseconds())
 
-	// Classify anonymity based on response and headers
-	if resp.Request.Header.Get("Via") != "" || resp.Request.Header.Get("X-Forwarded-For") != "" {
-		proxy.Anonymity = "Transparent"
-	} else if resp.Request.Header.Get("Forwarded") != "" {
-		proxy.Anonymity = "Anonymous"
-	} else {
-		proxy.Anonymity = "Elite"
+		anonymity, err := classifyAnonymity(cfg, client)
+		if err != nil {
+			log.Printf("Anonymity check failed for %s:%s: %v", proxy.IP, proxy.Port, err)
+			anonymity = "Unknown"
+		}
+		proxy.Anonymity = anonymity
+		return proxy
 	}
 
+	proxy.Alive = false
 	return proxy
 }
 
-// Fetch proxies from a specific source
-func fetchProxiesFromSource(url, sourceName string) ([]Proxy, error) {
-	client := &http.Client{Timeout: 15 * time.Second}
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", getRandomUserAgent())
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	var proxies []Proxy
-	tokenizer := html.NewTokenizer(resp.Body)
-	for {
-		tt := tokenizer.Next()
-		switch tt {
-		case html.ErrorToken:
-			return proxies, nil
-		case html.StartTagToken, html.SelfClosingTagToken:
-			t := tokenizer.Token()
-			if t.Data == "tr" {
-				proxy := extractProxyFromHTML(t, sourceName)
-				if proxy != nil {
-					proxies = append(proxies, *proxy)
-				}
-			}
-		}
-	}
+// waitForInterrupt blocks until the process receives SIGINT or SIGTERM, so
+// main doesn't return (and take background goroutines like RescheduleChecks
+// down with it) just because the one-shot harvest work is done.
+func waitForInterrupt() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	<-sigCh
 }
 
 // Helper function to extract proxy details from HTML
@@ -166,25 +133,47 @@ func extractProxyFromHTML(t html.Token, sourceName string) *Proxy {
 
 // Main function to coordinate scraping, validation, and classification
 func main() {
+	cfg, err := loadConfig("config.yaml")
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	parseFlags(&cfg)
+
 	// Load user agents and proxy sources
-	if err := loadUserAgents("useragents.txt"); err != nil {
+	if err := loadUserAgents(cfg.UserAgentsFile); err != nil {
 		log.Fatalf("Error loading user agents: %v", err)
 	}
 
-	if err := loadProxySources("ProxyList.json"); err != nil {
+	if err := loadProxySources(cfg.SourcesFile); err != nil {
 		log.Fatalf("Error loading proxy sources: %v", err)
 	}
 
+	store, err := OpenStore(cfg.StorePath)
+	if err != nil {
+		log.Fatalf("Error opening proxy store: %v", err)
+	}
+	defer store.Close()
+
+	if cfg.RecheckIntervalSeconds > 0 {
+		go RescheduleChecks(cfg, store, time.Duration(cfg.RecheckIntervalSeconds)*time.Second, make(chan struct{}))
+	}
+
 	var wg sync.WaitGroup
 	proxyChannel := make(chan Proxy)
 	checkedProxyChannel := make(chan Proxy)
 
 	// Fetch proxies concurrently from all sources
-	for sourceName, url := range proxySources {
+	for sourceName, srcCfg := range proxySources {
 		wg.Add(1)
-		go func(sourceName, url string) {
+		go func(sourceName string, srcCfg SourceConfig) {
 			defer wg.Done()
-			proxies, err := fetchProxiesFromSource(url, sourceName)
+			fetcher, err := newSourceFetcher(srcCfg)
+			if err != nil {
+				log.Printf("Error building fetcher for %s: %v", sourceName, err)
+				return
+			}
+
+			proxies, err := fetcher.Fetch(sourceName)
 			if err == nil {
 				for _, proxy := range proxies {
 					proxyChannel <- proxy
@@ -192,7 +181,7 @@ func main() {
 			} else {
 				log.Printf("Error fetching from %s: %v", sourceName, err)
 			}
-		}(sourceName, url)
+		}(sourceName, srcCfg)
 	}
 
 	go func() {
@@ -200,19 +189,40 @@ func main() {
 		close(proxyChannel)
 	}()
 
-	// Validate and classify proxies concurrently
+	// Validate and classify proxies through a bounded, adaptively sized
+	// worker pool rather than a goroutine per proxy.
 	var validationWG sync.WaitGroup
+	var scaler *ValidationScaler
+	scaler, err = newValidationScaler(cfg, func(proxy Proxy) {
+		defer validationWG.Done()
+		checkedProxy := checkAndClassifyProxy(cfg, proxy)
+		scaler.Done(checkedProxy.Alive, checkedProxy.ResponseMs)
+		checkedProxyChannel <- checkedProxy
+	})
+	if err != nil {
+		log.Fatalf("Failed to create validation worker pool: %v", err)
+	}
+	defer scaler.Release()
+	scaler.ServeStats(fmt.Sprintf(":%d", cfg.HTTPPort+1))
+
+	shrinkTicker := time.NewTicker(5 * time.Second)
+	go func() {
+		for range shrinkTicker.C {
+			scaler.Shrink()
+		}
+	}()
+
 	for proxy := range proxyChannel {
 		validationWG.Add(1)
-		go func(proxy Proxy) {
-			defer validationWG.Done()
-			checkedProxy := checkAndClassifyProxy(proxy)
-			checkedProxyChannel <- checkedProxy
-		}(proxy)
+		if err := scaler.Submit(proxy); err != nil {
+			log.Printf("Failed to submit proxy %s:%s for validation: %v", proxy.IP, proxy.Port, err)
+			validationWG.Done()
+		}
 	}
 
 	go func() {
 		validationWG.Wait()
+		shrinkTicker.Stop()
 		close(checkedProxyChannel)
 	}()
 
@@ -224,15 +234,50 @@ func main() {
 	defer file.Close()
 
 	writer := bufio.NewWriter(file)
+	proxiesByProtocol := newProxyResults()
 	for proxy := range checkedProxyChannel {
 		status := "Alive"
 		if !proxy.Alive {
 			status = "Dead"
+		} else {
+			proxiesByProtocol.dispatch(proxy)
+		}
+		if err := store.Record(proxy); err != nil {
+			log.Printf("Failed to record %s:%s in the proxy store: %v", proxy.IP, proxy.Port, err)
 		}
 		writer.WriteString(fmt.Sprintf("%s:%s | Source: %s | Protocol: %s | Anonymity: %s | Status: %s | Response Time: %dms\n",
 			proxy.IP, proxy.Port, proxy.Source, proxy.Protocol, proxy.Anonymity, status, proxy.ResponseMs))
 	}
 	writer.Flush()
 
-	log.Println("Proxy checking and classification completed successfully.")
+	log.Printf("Proxy checking and classification completed successfully: %d SOCKS5, %d SOCKS4a, %d SOCKS4, %d HTTP alive.",
+		len(proxiesByProtocol.SOCKS5), len(proxiesByProtocol.SOCKS4a), len(proxiesByProtocol.SOCKS4), len(proxiesByProtocol.HTTP))
+
+	// Rank every proxy the store has ever seen by rolling uptime and
+	// response time, so the load balancer prefers proxies that have
+	// proven reliable over many runs rather than just this one.
+	scored, err := store.Scored()
+	if err != nil {
+		log.Fatalf("Failed to load scored proxies: %v", err)
+	}
+	preferred := FilterScored(scored, cfg.MinUptime, cfg.MaxLatencyMs)
+	if cfg.ProxyPoolThirdparty > 0 && len(preferred) > cfg.ProxyPoolThirdparty {
+		preferred = preferred[:cfg.ProxyPoolThirdparty]
+	}
+	preferredProxies := make([]Proxy, len(preferred))
+	for i, sp := range preferred {
+		preferredProxies[i] = sp.Proxy
+	}
+
+	if serveMode {
+		if err := ListenAndServeLoadBalancer(cfg, oursFromConfig(cfg), preferredProxies); err != nil {
+			log.Fatalf("Load-balancing proxy server failed: %v", err)
+		}
+		return
+	}
+
+	if cfg.RecheckIntervalSeconds > 0 {
+		log.Printf("Harvest complete; blocking to keep periodic re-checks running every %ds. Press Ctrl+C to exit.", cfg.RecheckIntervalSeconds)
+		waitForInterrupt()
+	}
 }
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// maxPoolFailures is how many consecutive health-check failures a proxy
+// tolerates before it is evicted from rotation.
+const maxPoolFailures = 3
+
+// ProxyPool is a round-robin rotation of live proxies. Proxies that fail
+// the health check repeatedly are evicted from the rotation.
+type ProxyPool struct {
+	mu       sync.Mutex
+	proxies  []Proxy
+	next     int
+	failures map[string]int
+}
+
+func newProxyPool() *ProxyPool {
+	return &ProxyPool{failures: make(map[string]int)}
+}
+
+// Set replaces the pool's contents, e.g. after a fresh validation run.
+func (p *ProxyPool) Set(proxies []Proxy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.proxies = proxies
+	p.next = 0
+}
+
+// Next returns the next proxy in rotation, optionally restricted to the
+// given anonymity tier ("elite", "anonymous", "transparent"). An empty
+// tier matches any proxy.
+func (p *ProxyPool) Next(tier string) (Proxy, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := len(p.proxies)
+	if n == 0 {
+		return Proxy{}, false
+	}
+
+	for i := 0; i < n; i++ {
+		candidate := p.proxies[(p.next+i)%n]
+		if tier == "" || strings.EqualFold(candidate.Anonymity, tier) {
+			p.next = (p.next + i + 1) % n
+			return candidate, true
+		}
+	}
+	return Proxy{}, false
+}
+
+// MarkFailure records a failed request through proxy and evicts it once it
+// crosses maxPoolFailures.
+func (p *ProxyPool) MarkFailure(proxy Proxy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := proxy.IP + ":" + proxy.Port
+	p.failures[key]++
+	if p.failures[key] < maxPoolFailures {
+		return
+	}
+
+	for i, candidate := range p.proxies {
+		if candidate.IP == proxy.IP && candidate.Port == proxy.Port {
+			p.proxies = append(p.proxies[:i], p.proxies[i+1:]...)
+			break
+		}
+	}
+	delete(p.failures, key)
+}
+
+// MarkSuccess clears any accumulated failures for proxy.
+func (p *ProxyPool) MarkSuccess(proxy Proxy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.failures, proxy.IP+":"+proxy.Port)
+}
+
+// LoadBalancer exposes validated proxies as an upstream HTTP/HTTPS proxy,
+// dispatching client requests round-robin across an "ours" and a
+// "third-party" pool. Hostnames on the bypass list are routed through the
+// "ours" pool instead of third-party, so they never egress via a proxy we
+// don't control; X-Proxy-Bypass is the only way to skip proxying entirely.
+type LoadBalancer struct {
+	cfg              Config
+	ours             *ProxyPool
+	thirdparty       *ProxyPool
+	thirdpartyBypass []string
+}
+
+func newLoadBalancer(cfg Config) *LoadBalancer {
+	return &LoadBalancer{
+		cfg:              cfg,
+		ours:             newProxyPool(),
+		thirdparty:       newProxyPool(),
+		thirdpartyBypass: cfg.ThirdpartyBypassDomains,
+	}
+}
+
+// bypassesThirdparty reports whether host must never egress via the
+// third-party pool.
+func (lb *LoadBalancer) bypassesThirdparty(host string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range lb.thirdpartyBypass {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// poolFor picks the pool to route host's request through: "ours" for
+// hosts on the third-party bypass list, "third-party" otherwise.
+func (lb *LoadBalancer) poolFor(host string) *ProxyPool {
+	if lb.bypassesThirdparty(host) {
+		return lb.ours
+	}
+	return lb.thirdparty
+}
+
+func (lb *LoadBalancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Proxy-Bypass") != "" {
+		lb.serveDirect(w, r)
+		return
+	}
+
+	tier := strings.ToLower(r.Header.Get("X-Proxy-Tier"))
+	pool := lb.poolFor(r.Host)
+	proxy, ok := pool.Next(tier)
+	if !ok {
+		http.Error(w, "no live proxies available", http.StatusBadGateway)
+		return
+	}
+
+	if r.Method == http.MethodConnect {
+		lb.serveConnect(w, r, pool, proxy)
+		return
+	}
+	lb.serveForward(w, r, pool, proxy)
+}
+
+// serveDirect handles X-Proxy-Bypass requests by dialing the origin
+// directly, without going through any proxy in the pool.
+func (lb *LoadBalancer) serveDirect(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		dest, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		tunnel(w, dest)
+		return
+	}
+
+	resp, err := http.DefaultTransport.RoundTrip(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	copyResponse(w, resp)
+}
+
+// serveConnect transparently forwards a CONNECT tunnel through proxy
+// without terminating TLS.
+func (lb *LoadBalancer) serveConnect(w http.ResponseWriter, r *http.Request, pool *ProxyPool, proxy Proxy) {
+	upstream, err := net.Dial("tcp", net.JoinHostPort(proxy.IP, proxy.Port))
+	if err != nil {
+		pool.MarkFailure(proxy)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	fmt.Fprintf(upstream, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", r.Host, r.Host)
+	pool.MarkSuccess(proxy)
+	tunnel(w, upstream)
+}
+
+// tunnel hijacks the client connection and splices it with upstream,
+// leaving TLS (or any other protocol) untouched.
+func tunnel(w http.ResponseWriter, upstream net.Conn) {
+	defer upstream.Close()
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	client, _, err := hijacker.Hijack()
+	if err != nil {
+		log.Printf("failed to hijack connection: %v", err)
+		return
+	}
+	defer client.Close()
+
+	done := make(chan struct{}, 2)
+	go func() { io.Copy(upstream, client); done <- struct{}{} }()
+	go func() { io.Copy(client, upstream); done <- struct{}{} }()
+	<-done
+}
+
+// serveForward proxies a plain HTTP request through proxy.
+func (lb *LoadBalancer) serveForward(w http.ResponseWriter, r *http.Request, pool *ProxyPool, proxy Proxy) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyURL(&url.URL{Scheme: "http", Host: net.JoinHostPort(proxy.IP, proxy.Port)}),
+		},
+	}
+
+	resp, err := client.Do(r)
+	if err != nil {
+		pool.MarkFailure(proxy)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	pool.MarkSuccess(proxy)
+	copyResponse(w, resp)
+}
+
+// copyResponse writes an upstream response's headers, status, and body to
+// the client.
+func copyResponse(w http.ResponseWriter, resp *http.Response) {
+	for key, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(key, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// ListenAndServeLoadBalancer starts the round-robin load-balancing proxy
+// server, serving ours/thirdparty out of the given pools.
+func ListenAndServeLoadBalancer(cfg Config, ours, thirdparty []Proxy) error {
+	lb := newLoadBalancer(cfg)
+	lb.ours.Set(ours)
+	lb.thirdparty.Set(thirdparty)
+
+	addr := fmt.Sprintf(":%d", cfg.HTTPPort)
+	log.Printf("Load-balancing proxy listening on %s", addr)
+	return http.ListenAndServe(addr, lb)
+}
+
+// oursFromConfig parses cfg.ProxyPoolOurs's "ip:port" entries into the
+// "ours" pool, so proxy_pool_ours is a real, operator-supplied source
+// rather than relying on the harvester to have discovered them itself.
+// Entries it can't parse are skipped with a warning rather than failing
+// the whole pool.
+func oursFromConfig(cfg Config) []Proxy {
+	var ours []Proxy
+	for _, entry := range cfg.ProxyPoolOurs {
+		ip, port, err := net.SplitHostPort(entry)
+		if err != nil {
+			log.Printf("Skipping invalid proxy_pool_ours entry %q: %v", entry, err)
+			continue
+		}
+		ours = append(ours, Proxy{IP: ip, Port: port, Source: "ours", Alive: true})
+	}
+	return ours
+}
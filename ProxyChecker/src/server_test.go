@@ -0,0 +1,124 @@
+package main
+
+import "testing"
+
+func TestProxyPoolNextRoundRobin(t *testing.T) {
+	pool := newProxyPool()
+	pool.Set([]Proxy{
+		{IP: "1.1.1.1", Port: "80"},
+		{IP: "2.2.2.2", Port: "80"},
+	})
+
+	first, ok := pool.Next("")
+	if !ok || first.IP != "1.1.1.1" {
+		t.Fatalf("first Next() = %+v, %v, want 1.1.1.1", first, ok)
+	}
+	second, ok := pool.Next("")
+	if !ok || second.IP != "2.2.2.2" {
+		t.Fatalf("second Next() = %+v, %v, want 2.2.2.2", second, ok)
+	}
+	third, ok := pool.Next("")
+	if !ok || third.IP != "1.1.1.1" {
+		t.Fatalf("third Next() = %+v, %v, want wraparound to 1.1.1.1", third, ok)
+	}
+}
+
+func TestProxyPoolNextEmptyPool(t *testing.T) {
+	pool := newProxyPool()
+	if _, ok := pool.Next(""); ok {
+		t.Fatal("Next() on an empty pool should report ok = false")
+	}
+}
+
+func TestProxyPoolNextFiltersByTier(t *testing.T) {
+	pool := newProxyPool()
+	pool.Set([]Proxy{
+		{IP: "1.1.1.1", Port: "80", Anonymity: "Transparent"},
+		{IP: "2.2.2.2", Port: "80", Anonymity: "Elite"},
+	})
+
+	proxy, ok := pool.Next("elite")
+	if !ok || proxy.IP != "2.2.2.2" {
+		t.Fatalf("Next(\"elite\") = %+v, %v, want 2.2.2.2", proxy, ok)
+	}
+
+	if _, ok := pool.Next("anonymous"); ok {
+		t.Fatal("Next(\"anonymous\") should report ok = false when no proxy matches that tier")
+	}
+}
+
+func TestProxyPoolMarkFailureEviction(t *testing.T) {
+	pool := newProxyPool()
+	target := Proxy{IP: "1.1.1.1", Port: "80"}
+	pool.Set([]Proxy{target, {IP: "2.2.2.2", Port: "80"}})
+
+	for i := 0; i < maxPoolFailures-1; i++ {
+		pool.MarkFailure(target)
+		if _, ok := pool.Next(""); !ok {
+			t.Fatalf("proxy evicted too early, after %d failures", i+1)
+		}
+	}
+
+	pool.MarkFailure(target)
+	for i := 0; i < 2; i++ {
+		proxy, ok := pool.Next("")
+		if !ok {
+			t.Fatal("pool should still have the surviving proxy after eviction")
+		}
+		if proxy.IP == target.IP {
+			t.Fatalf("evicted proxy %s:%s was still returned by Next()", target.IP, target.Port)
+		}
+	}
+}
+
+func TestProxyPoolMarkSuccessResetsFailures(t *testing.T) {
+	pool := newProxyPool()
+	target := Proxy{IP: "1.1.1.1", Port: "80"}
+	pool.Set([]Proxy{target})
+
+	for i := 0; i < maxPoolFailures-1; i++ {
+		pool.MarkFailure(target)
+	}
+	pool.MarkSuccess(target)
+	pool.MarkFailure(target)
+
+	if _, ok := pool.Next(""); !ok {
+		t.Fatal("MarkSuccess should have reset the failure count, so the proxy should survive")
+	}
+}
+
+func TestLoadBalancerBypassesThirdparty(t *testing.T) {
+	lb := newLoadBalancer(Config{ThirdpartyBypassDomains: []string{"internal.example.com"}})
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"internal.example.com", true},
+		{"INTERNAL.EXAMPLE.COM", true},
+		{"api.internal.example.com", true},
+		{"internal.example.com.evil.com", false},
+		{"example.com", false},
+		{"unrelated.net", false},
+	}
+
+	for _, tt := range tests {
+		if got := lb.bypassesThirdparty(tt.host); got != tt.want {
+			t.Errorf("bypassesThirdparty(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestLoadBalancerPoolFor(t *testing.T) {
+	lb := newLoadBalancer(Config{ThirdpartyBypassDomains: []string{"internal.example.com"}})
+
+	if got := lb.poolFor("internal.example.com"); got != lb.ours {
+		t.Errorf("poolFor(bypass-listed host) routed to %p, want the ours pool %p", got, lb.ours)
+	}
+	if got := lb.poolFor("api.internal.example.com"); got != lb.ours {
+		t.Errorf("poolFor(bypass-listed subdomain) routed to %p, want the ours pool %p", got, lb.ours)
+	}
+	if got := lb.poolFor("example.com"); got != lb.thirdparty {
+		t.Errorf("poolFor(non-bypass host) routed to %p, want the thirdparty pool %p", got, lb.thirdparty)
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// sourceFetchTimeout bounds a single request made by any SourceFetcher.
+const sourceFetchTimeout = 15 * time.Second
+
+// maxPaginatedPages caps how many pages PaginatedAPIFetcher will follow,
+// so a misbehaving "next" link can't loop forever.
+const maxPaginatedPages = 50
+
+// SourceConfig describes one entry in ProxyList.json: where to fetch
+// proxies from, which fetcher handles that format, and how the fetcher
+// should pull proxy fields out of the response.
+type SourceConfig struct {
+	URL      string            `json:"url"`
+	Type     string            `json:"type"`               // html_table | plain_text | json | paginated_api
+	Selector string            `json:"selector,omitempty"` // dot-separated path to the proxy array, for json/paginated_api
+	Headers  map[string]string `json:"headers,omitempty"`
+	Auth     string            `json:"auth,omitempty"` // sent verbatim as the Authorization header
+}
+
+// SourceFetcher fetches the candidate proxies a single source publishes,
+// in whatever format that source uses.
+type SourceFetcher interface {
+	Fetch(sourceName string) ([]Proxy, error)
+}
+
+// newSourceFetcher builds the SourceFetcher matching cfg.Type. An empty
+// type defaults to html_table for backwards compatibility with existing
+// ProxyList.json entries.
+func newSourceFetcher(cfg SourceConfig) (SourceFetcher, error) {
+	switch cfg.Type {
+	case "", "html_table":
+		return &HTMLTableFetcher{cfg: cfg}, nil
+	case "plain_text":
+		return &PlainTextFetcher{cfg: cfg}, nil
+	case "json":
+		return &JSONFetcher{cfg: cfg}, nil
+	case "paginated_api":
+		return &PaginatedAPIFetcher{cfg: cfg}, nil
+	default:
+		return nil, fmt.Errorf("unknown source type %q", cfg.Type)
+	}
+}
+
+// newSourceRequest builds a GET request for targetURL with cfg's
+// configured headers and auth applied.
+func newSourceRequest(cfg SourceConfig, targetURL string) (*http.Request, error) {
+	req, err := http.NewRequest("GET", targetURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", getRandomUserAgent())
+	for key, value := range cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	if cfg.Auth != "" {
+		req.Header.Set("Authorization", cfg.Auth)
+	}
+	return req, nil
+}
+
+// HTMLTableFetcher scrapes <tr data-proxy="ip:port"> tables, the format
+// the harvester originally supported.
+type HTMLTableFetcher struct {
+	cfg SourceConfig
+}
+
+func (f *HTMLTableFetcher) Fetch(sourceName string) ([]Proxy, error) {
+	client := &http.Client{Timeout: sourceFetchTimeout}
+	req, err := newSourceRequest(f.cfg, f.cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var proxies []Proxy
+	tokenizer := html.NewTokenizer(resp.Body)
+	for {
+		tt := tokenizer.Next()
+		switch tt {
+		case html.ErrorToken:
+			return proxies, nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := tokenizer.Token()
+			if t.Data == "tr" {
+				if proxy := extractProxyFromHTML(t, sourceName); proxy != nil {
+					proxies = append(proxies, *proxy)
+				}
+			}
+		}
+	}
+}
+
+// PlainTextFetcher parses a newline-delimited list of "ip:port" entries,
+// the format most free proxy lists publish.
+type PlainTextFetcher struct {
+	cfg SourceConfig
+}
+
+func (f *PlainTextFetcher) Fetch(sourceName string) ([]Proxy, error) {
+	client := &http.Client{Timeout: sourceFetchTimeout}
+	req, err := newSourceRequest(f.cfg, f.cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var proxies []Proxy
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		proxies = append(proxies, Proxy{IP: parts[0], Port: parts[1], Source: sourceName})
+	}
+	return proxies, scanner.Err()
+}
+
+// JSONFetcher pulls proxies out of a JSON API response using cfg.Selector,
+// a dot-separated path (e.g. "data.proxies") naming the array of proxy
+// objects. Each object is expected to have "ip"/"port" fields.
+type JSONFetcher struct {
+	cfg SourceConfig
+}
+
+func (f *JSONFetcher) Fetch(sourceName string) ([]Proxy, error) {
+	client := &http.Client{Timeout: sourceFetchTimeout}
+	req, err := newSourceRequest(f.cfg, f.cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var payload interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	entries, err := selectJSONPath(payload, f.cfg.Selector)
+	if err != nil {
+		return nil, err
+	}
+	return proxiesFromJSONEntries(entries, sourceName), nil
+}
+
+// selectJSONPath walks a dot-separated path through decoded JSON and
+// returns the array it names. An empty path expects payload itself to be
+// the array.
+func selectJSONPath(payload interface{}, path string) ([]interface{}, error) {
+	current := payload
+	if path != "" {
+		for _, segment := range strings.Split(path, ".") {
+			obj, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("selector %q: %q is not an object", path, segment)
+			}
+			current, ok = obj[segment]
+			if !ok {
+				return nil, fmt.Errorf("selector %q: field %q not found", path, segment)
+			}
+		}
+	}
+
+	entries, ok := current.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("selector %q did not select an array", path)
+	}
+	return entries, nil
+}
+
+// proxiesFromJSONEntries converts decoded JSON objects with "ip"/"port"
+// fields into Proxy values, skipping entries it can't parse.
+func proxiesFromJSONEntries(entries []interface{}, sourceName string) []Proxy {
+	var proxies []Proxy
+	for _, entry := range entries {
+		obj, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		ip, _ := obj["ip"].(string)
+		port := jsonPortString(obj["port"])
+		if ip == "" || port == "" {
+			continue
+		}
+		proxies = append(proxies, Proxy{IP: ip, Port: port, Source: sourceName})
+	}
+	return proxies
+}
+
+// jsonPortString accepts a port encoded as either a JSON number or string.
+func jsonPortString(v interface{}) string {
+	switch port := v.(type) {
+	case string:
+		return port
+	case float64:
+		return strconv.Itoa(int(port))
+	default:
+		return ""
+	}
+}
+
+// PaginatedAPIFetcher follows a JSON API's "next" link until a page
+// yields no new proxies, applying the same selector as JSONFetcher to
+// each page.
+type PaginatedAPIFetcher struct {
+	cfg SourceConfig
+}
+
+func (f *PaginatedAPIFetcher) Fetch(sourceName string) ([]Proxy, error) {
+	client := &http.Client{Timeout: sourceFetchTimeout}
+
+	var all []Proxy
+	nextURL := f.cfg.URL
+	for page := 0; nextURL != "" && page < maxPaginatedPages; page++ {
+		req, err := newSourceRequest(f.cfg, nextURL)
+		if err != nil {
+			return all, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return all, err
+		}
+
+		var payload map[string]interface{}
+		decodeErr := json.NewDecoder(resp.Body).Decode(&payload)
+		resp.Body.Close()
+		if decodeErr != nil {
+			return all, decodeErr
+		}
+
+		entries, err := selectJSONPath(payload, f.cfg.Selector)
+		if err != nil {
+			return all, err
+		}
+
+		pageProxies := proxiesFromJSONEntries(entries, sourceName)
+		if len(pageProxies) == 0 {
+			break
+		}
+		all = append(all, pageProxies...)
+
+		next, _ := payload["next"].(string)
+		nextURL = next
+	}
+	return all, nil
+}
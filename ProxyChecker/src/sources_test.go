@@ -0,0 +1,72 @@
+package main
+
+import "testing"
+
+func TestSelectJSONPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload interface{}
+		path    string
+		wantLen int
+		wantErr bool
+	}{
+		{
+			name:    "empty path expects top-level array",
+			payload: []interface{}{"a", "b"},
+			path:    "",
+			wantLen: 2,
+		},
+		{
+			name: "nested path selects the array",
+			payload: map[string]interface{}{
+				"data": map[string]interface{}{
+					"proxies": []interface{}{"a", "b", "c"},
+				},
+			},
+			path:    "data.proxies",
+			wantLen: 3,
+		},
+		{
+			name:    "missing segment errors",
+			payload: map[string]interface{}{"data": map[string]interface{}{}},
+			path:    "data.proxies",
+			wantErr: true,
+		},
+		{
+			name:    "non-object intermediate errors",
+			payload: map[string]interface{}{"data": "not an object"},
+			path:    "data.proxies",
+			wantErr: true,
+		},
+		{
+			name:    "non-array result errors",
+			payload: map[string]interface{}{"data": map[string]interface{}{"proxies": "not an array"}},
+			path:    "data.proxies",
+			wantErr: true,
+		},
+		{
+			name:    "empty path with non-array payload errors",
+			payload: map[string]interface{}{"data": "x"},
+			path:    "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := selectJSONPath(tt.payload, tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got entries %v", entries)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(entries) != tt.wantLen {
+				t.Fatalf("got %d entries, want %d", len(entries), tt.wantLen)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// uptimeWindow bounds how many of a proxy's most recent checks factor
+// into its uptime percentage.
+const uptimeWindow = 20
+
+// emaAlpha weights how quickly a proxy's EMA response time reacts to new
+// samples; higher values track recent checks more closely.
+const emaAlpha = 0.3
+
+// Store persists proxies and their check history in SQLite (via the
+// cgo-free modernc.org/sqlite driver), so harvested proxies survive
+// across runs and their reliability can be scored over time instead of
+// judged on a single check.
+type Store struct {
+	db *sql.DB
+}
+
+// OpenStore opens (creating if necessary) the SQLite database at path and
+// ensures its schema exists.
+func OpenStore(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store: %v", err)
+	}
+
+	store := &Store{db: db}
+	if err := store.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *Store) migrate() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS proxies (
+			ip              TEXT NOT NULL,
+			port            TEXT NOT NULL,
+			source          TEXT,
+			protocol        TEXT,
+			anonymity       TEXT,
+			ema_response_ms REAL NOT NULL DEFAULT 0,
+			last_check      TEXT,
+			PRIMARY KEY (ip, port)
+		);
+
+		CREATE TABLE IF NOT EXISTS proxy_checks (
+			ip          TEXT NOT NULL,
+			port        TEXT NOT NULL,
+			checked_at  TEXT NOT NULL,
+			alive       INTEGER NOT NULL,
+			response_ms INTEGER NOT NULL,
+			protocol    TEXT,
+			anonymity   TEXT,
+			source      TEXT
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_proxy_checks_ip_port ON proxy_checks (ip, port, checked_at);
+	`)
+	return err
+}
+
+// Record upserts proxy and appends a row to its check history, rolling
+// its EMA response time forward.
+func (s *Store) Record(proxy Proxy) error {
+	checkedAt := time.Now().UTC().Format(time.RFC3339)
+
+	responseMs := 0.0
+	if proxy.Alive {
+		responseMs = float64(proxy.ResponseMs)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	var prevEMA float64
+	scanErr := tx.QueryRow(`SELECT ema_response_ms FROM proxies WHERE ip = ? AND port = ?`, proxy.IP, proxy.Port).Scan(&prevEMA)
+	if scanErr != nil && scanErr != sql.ErrNoRows {
+		tx.Rollback()
+		return scanErr
+	}
+
+	newEMA := responseMs
+	if scanErr == nil {
+		if proxy.Alive {
+			newEMA = emaAlpha*responseMs + (1-emaAlpha)*prevEMA
+		} else {
+			newEMA = prevEMA
+		}
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO proxies (ip, port, source, protocol, anonymity, ema_response_ms, last_check)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(ip, port) DO UPDATE SET
+			source = excluded.source,
+			protocol = excluded.protocol,
+			anonymity = excluded.anonymity,
+			ema_response_ms = excluded.ema_response_ms,
+			last_check = excluded.last_check
+	`, proxy.IP, proxy.Port, proxy.Source, proxy.Protocol, proxy.Anonymity, newEMA, checkedAt); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO proxy_checks (ip, port, checked_at, alive, response_ms, protocol, anonymity, source)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, proxy.IP, proxy.Port, checkedAt, proxy.Alive, proxy.ResponseMs, proxy.Protocol, proxy.Anonymity, proxy.Source); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ScoredProxy is a known proxy annotated with its rolling reliability
+// metrics.
+type ScoredProxy struct {
+	Proxy
+	UptimePercent float64
+	EMAResponseMs float64
+	Stability     float64 // 0-1, combines uptime and response-time consistency
+}
+
+// Scored returns every known proxy with its rolling uptime percentage
+// (over the last uptimeWindow checks), EMA response time, and stability
+// score.
+func (s *Store) Scored() ([]ScoredProxy, error) {
+	rows, err := s.db.Query(`SELECT ip, port, source, protocol, anonymity, ema_response_ms, last_check FROM proxies`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scored []ScoredProxy
+	for rows.Next() {
+		var sp ScoredProxy
+		if err := rows.Scan(&sp.IP, &sp.Port, &sp.Source, &sp.Protocol, &sp.Anonymity, &sp.EMAResponseMs, &sp.LastCheck); err != nil {
+			return nil, err
+		}
+
+		uptime, err := s.uptimePercent(sp.IP, sp.Port)
+		if err != nil {
+			return nil, err
+		}
+		sp.UptimePercent = uptime
+		sp.Alive = uptime > 0
+		sp.ResponseMs = int(sp.EMAResponseMs)
+		sp.Stability = stabilityScore(uptime, sp.EMAResponseMs)
+		scored = append(scored, sp)
+	}
+	return scored, rows.Err()
+}
+
+// uptimePercent computes the fraction of the last uptimeWindow checks for
+// (ip, port) that were alive.
+func (s *Store) uptimePercent(ip, port string) (float64, error) {
+	rows, err := s.db.Query(`
+		SELECT alive FROM proxy_checks
+		WHERE ip = ? AND port = ?
+		ORDER BY checked_at DESC
+		LIMIT ?
+	`, ip, port, uptimeWindow)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	total, alive := 0, 0
+	for rows.Next() {
+		var a bool
+		if err := rows.Scan(&a); err != nil {
+			return 0, err
+		}
+		total++
+		if a {
+			alive++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if total == 0 {
+		return 0, nil
+	}
+	return float64(alive) / float64(total), nil
+}
+
+// stabilityScore combines uptime and response-time consistency into a
+// single 0-1 figure, favoring proxies that are both reliable and fast.
+func stabilityScore(uptimePercent, emaResponseMs float64) float64 {
+	if uptimePercent == 0 {
+		return 0
+	}
+	// Normalize EMA response time against a 5s ceiling; anything slower
+	// contributes ~nothing to the score.
+	speedFactor := 1 - emaResponseMs/5000
+	if speedFactor < 0 {
+		speedFactor = 0
+	}
+	return 0.7*uptimePercent + 0.3*speedFactor
+}
+
+// FilterScored keeps only proxies meeting the given uptime and latency
+// thresholds, sorted by stability score descending so the most reliable,
+// fastest proxies come first.
+func FilterScored(scored []ScoredProxy, minUptime float64, maxLatencyMs int) []ScoredProxy {
+	var kept []ScoredProxy
+	for _, sp := range scored {
+		if sp.UptimePercent < minUptime {
+			continue
+		}
+		if maxLatencyMs > 0 && int(sp.EMAResponseMs) > maxLatencyMs {
+			continue
+		}
+		kept = append(kept, sp)
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].Stability > kept[j].Stability })
+	return kept
+}
+
+// Close closes the underlying database handle.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// RescheduleChecks periodically re-validates every proxy the store
+// already knows about, independent of scraping runs, so reliability
+// scores stay fresh between harvests. It blocks until stop is closed.
+func RescheduleChecks(cfg Config, store *Store, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			scored, err := store.Scored()
+			if err != nil {
+				log.Printf("Periodic re-check: failed to load known proxies: %v", err)
+				continue
+			}
+			for _, sp := range scored {
+				checked := checkAndClassifyProxy(cfg, sp.Proxy)
+				if err := store.Record(checked); err != nil {
+					log.Printf("Periodic re-check: failed to record %s:%s: %v", checked.IP, checked.Port, err)
+				}
+			}
+		}
+	}
+}
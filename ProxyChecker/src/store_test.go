@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestStabilityScore(t *testing.T) {
+	tests := []struct {
+		name          string
+		uptimePercent float64
+		emaResponseMs float64
+		want          float64
+	}{
+		{name: "zero uptime scores zero regardless of speed", uptimePercent: 0, emaResponseMs: 10, want: 0},
+		{name: "perfect uptime and instant response scores 1", uptimePercent: 1, emaResponseMs: 0, want: 1},
+		{name: "response slower than the 5s ceiling contributes nothing", uptimePercent: 1, emaResponseMs: 6000, want: 0.7},
+		{name: "half uptime, half-ceiling latency", uptimePercent: 0.5, emaResponseMs: 2500, want: 0.7*0.5 + 0.3*0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stabilityScore(tt.uptimePercent, tt.emaResponseMs)
+			if got != tt.want {
+				t.Fatalf("stabilityScore(%v, %v) = %v, want %v", tt.uptimePercent, tt.emaResponseMs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterScored(t *testing.T) {
+	scored := []ScoredProxy{
+		{Proxy: Proxy{IP: "1.1.1.1"}, UptimePercent: 0.9, EMAResponseMs: 100, Stability: 0.9},
+		{Proxy: Proxy{IP: "2.2.2.2"}, UptimePercent: 0.2, EMAResponseMs: 50, Stability: 0.3},
+		{Proxy: Proxy{IP: "3.3.3.3"}, UptimePercent: 0.95, EMAResponseMs: 9000, Stability: 0.7},
+		{Proxy: Proxy{IP: "4.4.4.4"}, UptimePercent: 0.99, EMAResponseMs: 200, Stability: 0.99},
+	}
+
+	kept := FilterScored(scored, 0.5, 5000)
+	if len(kept) != 2 {
+		t.Fatalf("got %d kept proxies, want 2: %+v", len(kept), kept)
+	}
+	if kept[0].IP != "4.4.4.4" || kept[1].IP != "1.1.1.1" {
+		t.Fatalf("kept proxies not sorted by stability descending: %+v", kept)
+	}
+}
+
+func TestFilterScoredNoThresholds(t *testing.T) {
+	scored := []ScoredProxy{
+		{Proxy: Proxy{IP: "1.1.1.1"}, UptimePercent: 0, EMAResponseMs: 9999, Stability: 0},
+	}
+
+	kept := FilterScored(scored, 0, 0)
+	if len(kept) != 1 {
+		t.Fatalf("got %d kept proxies, want 1 when thresholds are unset", len(kept))
+	}
+}